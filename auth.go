@@ -0,0 +1,72 @@
+package wire
+
+import "context"
+
+// Authenticator negotiates client authentication for a new connection,
+// after the startup message has been read but before any query is
+// accepted. Implementations drive whatever exchange their mechanism
+// requires (e.g. SASL/SCRAM) over the SASLExchange handed to them.
+type Authenticator interface {
+	// Mechanisms returns the SASL mechanism names this Authenticator
+	// supports, in preference order. They are advertised to the client in
+	// AuthenticationSASL.
+	Mechanisms() []string
+
+	// Authenticate runs the authentication exchange for user and returns
+	// nil once the client has proven its identity. A non-nil error fails
+	// the connection with an authentication error.
+	Authenticate(ctx context.Context, user string, exchange SASLExchange) error
+}
+
+// SASLExchange is the connection-facing half of a SASL authentication
+// exchange: it lets an Authenticator shuttle challenge/response messages
+// without needing access to the connection's internal framing.
+type SASLExchange interface {
+	// InitialResponse sends AuthenticationSASL advertising mechanisms, in
+	// preference order, and reads back the client's SASLInitialResponse
+	// payload (which may be empty, if the client chose to send its initial
+	// data in a later message). It is the client, not the server, that
+	// picks which of the advertised mechanisms to use.
+	InitialResponse(mechanisms []string) ([]byte, error)
+
+	// Continue sends challenge as an AuthenticationSASLContinue message
+	// and returns the client's next SASLResponse payload.
+	Continue(challenge []byte) ([]byte, error)
+
+	// Final sends data as an AuthenticationSASLFinal message, completing
+	// the exchange.
+	Final(data []byte) error
+
+	// ChannelBinding returns the `tls-server-end-point` channel binding
+	// data for the underlying connection, and whether one is available.
+	// It is only available when the connection is using TLS, and backs
+	// mechanisms such as SCRAM-SHA-256-PLUS.
+	ChannelBinding() ([]byte, bool)
+}
+
+// WithAuth configures the Authenticator used to authenticate new
+// connections, replacing the server's default trust-style handling. Use
+// this to require SASL/SCRAM (see the scram subpackage) or another
+// mechanism before a connection is allowed to issue queries.
+func WithAuth(auth Authenticator) Option {
+	return func(srv *Server) {
+		srv.authenticator = auth
+	}
+}
+
+// Authenticate runs the server's configured Authenticator for a new
+// connection, if one was installed via WithAuth. The connection's startup
+// path should call this once, after reading the StartupMessage and before
+// accepting any query, passing the SASLExchange backed by that connection;
+// this package has no startup/dispatch loop of its own for new connections
+// to call it from, so wiring it in is left to that path.
+//
+// When no Authenticator is configured, Authenticate returns nil
+// immediately, preserving the server's default trust-style behavior.
+func (srv *Server) Authenticate(ctx context.Context, user string, exchange SASLExchange) error {
+	if srv.authenticator == nil {
+		return nil
+	}
+
+	return srv.authenticator.Authenticate(ctx, user, exchange)
+}
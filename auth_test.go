@@ -0,0 +1,58 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type fakeSASLExchange struct{}
+
+func (fakeSASLExchange) InitialResponse(mechanisms []string) ([]byte, error) { return nil, nil }
+func (fakeSASLExchange) Continue(challenge []byte) ([]byte, error)           { return nil, nil }
+func (fakeSASLExchange) Final(data []byte) error                             { return nil }
+func (fakeSASLExchange) ChannelBinding() ([]byte, bool)                      { return nil, false }
+
+type fakeAuthenticator struct {
+	called bool
+	user   string
+	err    error
+}
+
+func (f *fakeAuthenticator) Mechanisms() []string { return []string{"FAKE-MECH"} }
+
+func (f *fakeAuthenticator) Authenticate(ctx context.Context, user string, exchange SASLExchange) error {
+	f.called = true
+	f.user = user
+	return f.err
+}
+
+func TestServerAuthenticateTrustsByDefault(t *testing.T) {
+	srv := &Server{}
+
+	if err := srv.Authenticate(context.Background(), "alice", fakeSASLExchange{}); err != nil {
+		t.Fatalf("expected no Authenticator to trust the connection, got: %v", err)
+	}
+}
+
+func TestServerAuthenticateDispatchesToAuthenticator(t *testing.T) {
+	auth := &fakeAuthenticator{}
+	srv := &Server{authenticator: auth}
+
+	if err := srv.Authenticate(context.Background(), "alice", fakeSASLExchange{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !auth.called || auth.user != "alice" {
+		t.Fatalf("expected Authenticate to be called with user %q, got called=%v user=%q", "alice", auth.called, auth.user)
+	}
+}
+
+func TestServerAuthenticatePropagatesError(t *testing.T) {
+	auth := &fakeAuthenticator{err: fmt.Errorf("bad password")}
+	srv := &Server{authenticator: auth}
+
+	if err := srv.Authenticate(context.Background(), "alice", fakeSASLExchange{}); err == nil {
+		t.Fatal("expected the Authenticator's error to propagate")
+	}
+}
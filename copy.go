@@ -0,0 +1,338 @@
+package wire
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// CopyFormat selects the wire representation used for a COPY stream, mirroring
+// the `format` field Postgres sends in CopyInResponse/CopyOutResponse.
+type CopyFormat int16
+
+// Supported COPY formats.
+const (
+	CopyFormatText   CopyFormat = 0
+	CopyFormatBinary CopyFormat = 1
+)
+
+// Client-to-server message types that make up the COPY subprotocol.
+const (
+	copyDataMessage = 'd'
+	copyDoneMessage = 'c'
+	copyFailMessage = 'f'
+)
+
+// CopyWriter streams rows to the client as part of a COPY TO STDOUT
+// (CopyOut) response. Rows written through it are framed as CopyData
+// messages; Close flushes the final CopyDone.
+type CopyWriter interface {
+	// WriteRow encodes and sends a single row using the format negotiated
+	// for this copy stream.
+	WriteRow(values []any) error
+
+	// Close sends CopyDone, signalling to the client that the stream is
+	// complete. It must be called exactly once, after the last WriteRow.
+	Close() error
+}
+
+// CopyReader exposes an in-progress COPY FROM STDIN (CopyIn) stream to a
+// handler. It implements io.Reader over the raw bytes of the incoming
+// CopyData messages so handlers can pipe it straight into a bulk loader.
+type CopyReader interface {
+	io.Reader
+
+	// Fail aborts the copy, causing the client to see an error instead of
+	// a successful completion. Handlers should call this instead of
+	// returning a plain error once a CopyIn stream has been opened, so the
+	// client's driver leaves COPY mode cleanly.
+	Fail(message string) error
+}
+
+// BindWriter attaches writer's underlying connection to ctx's Session, so
+// CopyOut/CopyIn (and asynchronous NOTIFY delivery, see notify.go) have a
+// real connection to write to. writer should be the DataWriter the
+// server's query-dispatch path already hands the handler for the query
+// currently running on this session. This is the only way this package
+// can get hold of a real conn at all: BackendKeyData's hook signature
+// (func(ctx) (int32, int32)) never receives one, so Session.conn can't be
+// populated at connection-startup time the way ProcessID/SecretKey are.
+// The handler's own first query is the earliest point a conn becomes
+// reachable, so the server's query-dispatch path (or a thin wrapper
+// installed once around the configured handler) should call this before
+// running any query that might use CopyOut/CopyIn/NOTIFY.
+//
+// writer is accepted as any, rather than as DataWriter, because not every
+// DataWriter implementation is necessarily backed by a connection capable
+// of COPY/NOTIFY framing (e.g. a test double). BindWriter silently does
+// nothing if writer doesn't implement the capability these features need.
+func BindWriter(ctx context.Context, writer any) {
+	session := CurrentSession(ctx)
+	if session == nil {
+		return
+	}
+
+	conn, ok := writer.(sessionConn)
+	if !ok {
+		return
+	}
+
+	session.mutex.Lock()
+	session.conn = conn
+	session.mutex.Unlock()
+}
+
+// CopyOut begins a COPY TO STDOUT response on the session's connection,
+// sending CopyOutResponse and returning a CopyWriter handlers can stream
+// rows through. Obtain the Session via CurrentSession(ctx); the session's
+// connection must already have been attached via BindWriter, or this
+// returns an error.
+func (s *Session) CopyOut(ctx context.Context, format CopyFormat, columns Columns) (CopyWriter, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("wire: session has no connection to copy out on")
+	}
+
+	return newCopyWriter(ctx, s.conn, format, columns)
+}
+
+// CopyIn begins a COPY FROM STDIN request on the session's connection,
+// sending CopyInResponse and returning a CopyReader handlers can read the
+// incoming bulk data from. Obtain the Session via CurrentSession(ctx); the
+// session's connection must already have been attached via BindWriter, or
+// this returns an error.
+func (s *Session) CopyIn(ctx context.Context, format CopyFormat, columns Columns) (CopyReader, error) {
+	if s.conn == nil {
+		return nil, fmt.Errorf("wire: session has no connection to copy in from")
+	}
+
+	return newCopyReader(ctx, s.conn, format, len(columns))
+}
+
+type copyWriter struct {
+	conn    sessionConn
+	columns Columns
+	format  CopyFormat
+	ctx     context.Context
+	closed  bool
+}
+
+func newCopyWriter(ctx context.Context, c sessionConn, format CopyFormat, columns Columns) (*copyWriter, error) {
+	if err := c.writeCopyOutResponse(format, len(columns)); err != nil {
+		return nil, fmt.Errorf("wire: writing CopyOutResponse: %w", err)
+	}
+
+	return &copyWriter{conn: c, columns: columns, format: format, ctx: ctx}, nil
+}
+
+func (w *copyWriter) WriteRow(values []any) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	var buf []byte
+	var err error
+
+	switch w.format {
+	case CopyFormatBinary:
+		buf, err = encodeCopyBinaryRow(w.columns, values)
+	default:
+		buf, err = encodeCopyTextRow(w.columns, values)
+	}
+
+	if err != nil {
+		return fmt.Errorf("wire: encoding copy row: %w", err)
+	}
+
+	return w.conn.writeCopyData(buf)
+}
+
+func (w *copyWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	return w.conn.writeCopyDone()
+}
+
+type copyReader struct {
+	conn     sessionConn
+	ctx      context.Context
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+	reader   *bufio.Reader
+	messages chan copyReadResult
+}
+
+// copyReadResult carries one readCopyMessage() call's result across to
+// pump, so pump can select on it alongside ctx.Done() instead of being
+// stuck inside the blocking call itself.
+type copyReadResult struct {
+	kind    byte
+	payload []byte
+	err     error
+}
+
+func newCopyReader(ctx context.Context, c sessionConn, format CopyFormat, columns int) (*copyReader, error) {
+	if err := c.writeCopyInResponse(format, columns); err != nil {
+		return nil, fmt.Errorf("wire: writing CopyInResponse: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	reader := &copyReader{conn: c, ctx: ctx, pr: pr, pw: pw, messages: make(chan copyReadResult)}
+	reader.reader = bufio.NewReader(pr)
+
+	go reader.readLoop()
+	go reader.pump()
+	return reader, nil
+}
+
+// readLoop does nothing but call the blocking readCopyMessage() in a loop
+// and forward each result to messages, so pump is never itself parked
+// inside that blocking call and can always react to ctx.Done() right
+// away. It exits once ctx is done or readCopyMessage errors; a readLoop
+// left blocked inside readCopyMessage when ctx is canceled leaks until
+// the underlying connection's teardown unblocks it, same as a read
+// deadline would if the conn doesn't support one.
+func (r *copyReader) readLoop() {
+	for {
+		msg, payload, err := r.conn.readCopyMessage()
+
+		select {
+		case r.messages <- copyReadResult{kind: msg, payload: payload, err: err}:
+		case <-r.ctx.Done():
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// pump reads CopyData/CopyDone/CopyFail messages off readLoop and feeds
+// the raw payload bytes into the pipe backing Read, so a handler can
+// consume the stream with ordinary io.Reader semantics. Racing ctx.Done()
+// against messages (rather than polling between blocking reads) is what
+// lets a canceled context translate into a CopyFail promptly even while
+// readLoop is parked inside a slow or stalled client's read.
+func (r *copyReader) pump() {
+	for {
+		select {
+		case <-r.ctx.Done():
+			_ = r.pw.CloseWithError(r.ctx.Err())
+			_ = r.conn.writeCopyFail("context canceled")
+			return
+		case result := <-r.messages:
+			if result.err != nil {
+				_ = r.pw.CloseWithError(result.err)
+				return
+			}
+
+			switch result.kind {
+			case copyDataMessage:
+				if _, err := r.pw.Write(result.payload); err != nil {
+					_ = r.conn.writeCopyFail(err.Error())
+					return
+				}
+			case copyDoneMessage:
+				_ = r.pw.Close()
+				return
+			case copyFailMessage:
+				_ = r.pw.CloseWithError(fmt.Errorf("wire: client aborted copy: %s", result.payload))
+				return
+			}
+		}
+	}
+}
+
+func (r *copyReader) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r *copyReader) Fail(message string) error {
+	_ = r.pr.Close()
+	return r.conn.writeCopyFail(message)
+}
+
+// encodeCopyTextRow renders a row using Postgres' COPY text format: tab
+// separated fields, rows terminated with a newline, with \N for NULL.
+func encodeCopyTextRow(columns Columns, values []any) ([]byte, error) {
+	if len(values) != len(columns) {
+		return nil, fmt.Errorf("wire: expected %d values, got %d", len(columns), len(values))
+	}
+
+	buf := make([]byte, 0, 64)
+	for i, value := range values {
+		if i > 0 {
+			buf = append(buf, '\t')
+		}
+
+		if value == nil {
+			buf = append(buf, '\\', 'N')
+			continue
+		}
+
+		buf = append(buf, escapeCopyText([]byte(fmt.Sprint(value)))...)
+	}
+
+	return append(buf, '\n'), nil
+}
+
+// encodeCopyBinaryRow renders a row using Postgres' COPY binary per-tuple
+// format: a field count followed by each field's length-prefixed bytes.
+func encodeCopyBinaryRow(columns Columns, values []any) ([]byte, error) {
+	if len(values) != len(columns) {
+		return nil, fmt.Errorf("wire: expected %d values, got %d", len(columns), len(values))
+	}
+
+	buf := make([]byte, 2, 64)
+	binary.BigEndian.PutUint16(buf, uint16(len(values)))
+
+	for _, value := range values {
+		if value == nil {
+			buf = binary.BigEndian.AppendUint32(buf, 0xFFFFFFFF)
+			continue
+		}
+
+		encoded := []byte(fmt.Sprint(value))
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(encoded)))
+		buf = append(buf, encoded...)
+	}
+
+	return buf, nil
+}
+
+func escapeCopyText(b []byte) []byte {
+	needsEscape := false
+	for _, c := range b {
+		if c == '\\' || c == '\t' || c == '\n' || c == '\r' {
+			needsEscape = true
+			break
+		}
+	}
+
+	if !needsEscape {
+		return b
+	}
+
+	out := make([]byte, 0, len(b)+4)
+	for _, c := range b {
+		switch c {
+		case '\\':
+			out = append(out, '\\', '\\')
+		case '\t':
+			out = append(out, '\\', 't')
+		case '\n':
+			out = append(out, '\\', 'n')
+		case '\r':
+			out = append(out, '\\', 'r')
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
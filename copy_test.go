@@ -0,0 +1,261 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSessionConn is a sessionConn double used to exercise session-scoped
+// features (COPY, NOTIFY) without a real connection.
+type fakeSessionConn struct {
+	mutex sync.Mutex
+
+	copyOutFormat  CopyFormat
+	copyOutColumns int
+	rows           [][]byte
+	copyDone       bool
+	copyFailMsg    string
+
+	copyInFormat  CopyFormat
+	copyInColumns int
+	inbox         chan copyMessage
+
+	notifications []fakeNotification
+}
+
+type copyMessage struct {
+	kind    byte
+	payload []byte
+}
+
+type fakeNotification struct {
+	pid     int32
+	channel string
+	payload string
+}
+
+func newFakeSessionConn() *fakeSessionConn {
+	return &fakeSessionConn{inbox: make(chan copyMessage, 16)}
+}
+
+func (f *fakeSessionConn) writeNotificationResponse(pid int32, channel, payload string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.notifications = append(f.notifications, fakeNotification{pid, channel, payload})
+	return nil
+}
+
+func (f *fakeSessionConn) writeCopyOutResponse(format CopyFormat, columns int) error {
+	f.copyOutFormat = format
+	f.copyOutColumns = columns
+	return nil
+}
+
+func (f *fakeSessionConn) writeCopyInResponse(format CopyFormat, columns int) error {
+	f.copyInFormat = format
+	f.copyInColumns = columns
+	return nil
+}
+
+func (f *fakeSessionConn) writeCopyData(buf []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	cp := append([]byte(nil), buf...)
+	f.rows = append(f.rows, cp)
+	return nil
+}
+
+func (f *fakeSessionConn) writeCopyDone() error {
+	f.copyDone = true
+	return nil
+}
+
+func (f *fakeSessionConn) writeCopyFail(message string) error {
+	f.copyFailMsg = message
+	return nil
+}
+
+func (f *fakeSessionConn) readCopyMessage() (byte, []byte, error) {
+	msg, ok := <-f.inbox
+	if !ok {
+		return 0, nil, fmt.Errorf("fakeSessionConn: closed")
+	}
+	return msg.kind, msg.payload, nil
+}
+
+func (f *fakeSessionConn) sendCopyData(payload []byte) {
+	f.inbox <- copyMessage{kind: copyDataMessage, payload: payload}
+}
+
+func (f *fakeSessionConn) sendCopyDone() {
+	f.inbox <- copyMessage{kind: copyDoneMessage}
+}
+
+func TestEncodeCopyTextRow(t *testing.T) {
+	columns := Columns{{Name: "a"}, {Name: "b"}}
+
+	row, err := encodeCopyTextRow(columns, []any{1, nil})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(row, []byte("1\t\\N\n")) {
+		t.Fatalf("unexpected encoding: %q", row)
+	}
+}
+
+func TestEscapeCopyText(t *testing.T) {
+	got := escapeCopyText([]byte("a\tb\nc"))
+	want := []byte(`a\tb\nc`)
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestSessionCopyOutStreamsRows(t *testing.T) {
+	fake := newFakeSessionConn()
+	session := &Session{ProcessID: 1, conn: fake}
+	columns := Columns{{Name: "id"}}
+
+	writer, err := session.CopyOut(context.Background(), CopyFormatText, columns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fake.copyOutColumns != 1 {
+		t.Fatalf("expected CopyOutResponse for 1 column, got %d", fake.copyOutColumns)
+	}
+
+	if err := writer.WriteRow([]any{42}); err != nil {
+		t.Fatalf("unexpected error writing row: %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	if len(fake.rows) != 1 || !bytes.Equal(fake.rows[0], []byte("42\n")) {
+		t.Fatalf("unexpected rows written: %q", fake.rows)
+	}
+
+	if !fake.copyDone {
+		t.Fatal("expected Close to send CopyDone")
+	}
+}
+
+func TestSessionCopyOutWithoutConn(t *testing.T) {
+	session := &Session{ProcessID: 1}
+
+	if _, err := session.CopyOut(context.Background(), CopyFormatText, Columns{{Name: "id"}}); err == nil {
+		t.Fatal("expected an error when the session has no connection")
+	}
+}
+
+func TestSessionCopyInReadsStreamedData(t *testing.T) {
+	fake := newFakeSessionConn()
+	session := &Session{ProcessID: 1, conn: fake}
+	columns := Columns{{Name: "id"}}
+
+	reader, err := session.CopyIn(context.Background(), CopyFormatText, columns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fake.copyInColumns != 1 {
+		t.Fatalf("expected CopyInResponse for 1 column, got %d", fake.copyInColumns)
+	}
+
+	fake.sendCopyData([]byte("1\n2\n"))
+	fake.sendCopyDone()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unexpected error reading copy stream: %v", err)
+	}
+
+	if !bytes.Equal(got, []byte("1\n2\n")) {
+		t.Fatalf("unexpected copy stream contents: %q", got)
+	}
+}
+
+func TestSessionCopyInCancelSendsCopyFailPromptly(t *testing.T) {
+	fake := newFakeSessionConn()
+	session := &Session{ProcessID: 1, conn: fake}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, err := session.CopyIn(ctx, CopyFormatText, Columns{{Name: "id"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// fake.inbox never receives anything, so readCopyMessage blocks
+	// forever; pump must still notice ctx is done without waiting for a
+	// message to arrive.
+	cancel()
+
+	_, err = io.ReadAll(reader)
+	if err == nil {
+		t.Fatal("expected reading the copy stream to fail once its context was canceled")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for fake.copyFailMsg == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if fake.copyFailMsg != "context canceled" {
+		t.Fatalf("expected a CopyFail for the canceled context, got %q", fake.copyFailMsg)
+	}
+}
+
+func TestBindWriterAttachesConnForCopy(t *testing.T) {
+	fake := newFakeSessionConn()
+	session := &Session{ProcessID: 1}
+	ctx := withSession(context.Background(), session)
+
+	BindWriter(ctx, fake)
+
+	writer, err := session.CopyOut(ctx, CopyFormatText, Columns{{Name: "id"}})
+	if err != nil {
+		t.Fatalf("expected CopyOut to succeed once BindWriter attached a conn, got: %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBindWriterIgnoresIncompatibleWriter(t *testing.T) {
+	session := &Session{ProcessID: 1}
+	ctx := withSession(context.Background(), session)
+
+	BindWriter(ctx, "not a sessionConn")
+
+	if session.conn != nil {
+		t.Fatal("expected BindWriter to leave conn unset for an incompatible writer")
+	}
+}
+
+func TestSessionCopyInFail(t *testing.T) {
+	fake := newFakeSessionConn()
+	session := &Session{ProcessID: 1, conn: fake}
+
+	reader, err := session.CopyIn(context.Background(), CopyFormatText, Columns{{Name: "id"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := reader.Fail("bad input"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fake.copyFailMsg != "bad input" {
+		t.Fatalf("expected CopyFail message %q, got %q", "bad input", fake.copyFailMsg)
+	}
+}
@@ -0,0 +1,152 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Notifier delivers asynchronous NotificationResponse ('A') messages to
+// clients, implementing the server side of Postgres' LISTEN/NOTIFY
+// subsystem. Obtain one for the current connection via CurrentNotifier.
+type Notifier interface {
+	// Notify delivers payload on channel to every session currently
+	// listening on it, including ones handled by other connections. It
+	// mirrors the Postgres NOTIFY command.
+	Notify(ctx context.Context, channel, payload string) error
+}
+
+// ListenHandler is invoked whenever a client issues LISTEN or UNLISTEN, so
+// that callers can audit or reject subscriptions before the server starts
+// tracking them. A non-nil error causes the request to be rejected.
+type ListenHandler func(ctx context.Context, channel string) error
+
+// WithListenHandler installs a callback invoked on every LISTEN/UNLISTEN
+// issued by a client, in addition to the server's own channel bookkeeping.
+func WithListenHandler(fn ListenHandler) Option {
+	return func(srv *Server) {
+		srv.listenHandler = fn
+	}
+}
+
+// serverNotifier is the Notifier handed out by CurrentNotifier. It fans a
+// notification out to every session registered with the owning server that
+// is currently listening on the target channel.
+type serverNotifier struct {
+	server *Server
+}
+
+func (n *serverNotifier) Notify(ctx context.Context, channel, payload string) error {
+	return n.server.Broadcast(ctx, channel, payload)
+}
+
+// CurrentNotifier returns a Notifier scoped to the server handling ctx's
+// connection, or nil if ctx was not derived from a connection handled by
+// this package.
+func CurrentNotifier(ctx context.Context) Notifier {
+	session := CurrentSession(ctx)
+	if session == nil || session.server == nil {
+		return nil
+	}
+
+	return &serverNotifier{server: session.server}
+}
+
+// listenChannel marks the session as listening on channel, honoring a LISTEN
+// command. It invokes the server's ListenHandler, if configured, before
+// recording the subscription.
+func (srv *Server) listenChannel(ctx context.Context, channel string) error {
+	if srv.listenHandler != nil {
+		if err := srv.listenHandler(ctx, channel); err != nil {
+			return fmt.Errorf("wire: listen rejected for channel %q: %w", channel, err)
+		}
+	}
+
+	session := CurrentSession(ctx)
+	if session == nil {
+		return fmt.Errorf("wire: no session associated with context")
+	}
+
+	session.listen(channel)
+	return nil
+}
+
+// unlistenChannel removes the session's subscription to channel, honoring
+// an UNLISTEN command.
+func (srv *Server) unlistenChannel(ctx context.Context, channel string) error {
+	if srv.listenHandler != nil {
+		if err := srv.listenHandler(ctx, channel); err != nil {
+			return fmt.Errorf("wire: unlisten rejected for channel %q: %w", channel, err)
+		}
+	}
+
+	session := CurrentSession(ctx)
+	if session == nil {
+		return fmt.Errorf("wire: no session associated with context")
+	}
+
+	session.unlisten(channel)
+	return nil
+}
+
+// InterceptListenCommand recognizes a simple-query LISTEN or UNLISTEN
+// statement and applies it to ctx's Session, returning handled=true if sql
+// was one of these and has already been fully dealt with. The server's
+// simple query path must call this before handing sql off to the
+// configured query handler, since LISTEN/UNLISTEN are handled entirely by
+// this package rather than by user code.
+func (srv *Server) InterceptListenCommand(ctx context.Context, sql string) (handled bool, err error) {
+	stmt := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(sql), ";"))
+	fields := strings.Fields(stmt)
+	if len(fields) != 2 {
+		return false, nil
+	}
+
+	channel := strings.Trim(fields[1], `"`)
+
+	switch strings.ToUpper(fields[0]) {
+	case "LISTEN":
+		return true, srv.listenChannel(ctx, channel)
+	case "UNLISTEN":
+		if channel == "*" {
+			session := CurrentSession(ctx)
+			if session == nil {
+				return true, fmt.Errorf("wire: no session associated with context")
+			}
+
+			// Route each channel through unlistenChannel individually,
+			// rather than calling session.unlisten directly, so a
+			// configured ListenHandler gets the same chance to audit or
+			// reject an unlisten-all that it gets for a single UNLISTEN.
+			for _, ch := range session.listeningChannels() {
+				if err := srv.unlistenChannel(ctx, ch); err != nil {
+					return true, err
+				}
+			}
+			return true, nil
+		}
+		return true, srv.unlistenChannel(ctx, channel)
+	default:
+		return false, nil
+	}
+}
+
+// Broadcast delivers payload on channel to every currently registered
+// session that is listening on it. Each delivery is serialized against that
+// session's normal response stream so a NotificationResponse is only
+// flushed at a safe protocol boundary (i.e. never mid-row, mid-message).
+func (srv *Server) Broadcast(ctx context.Context, channel, payload string) error {
+	var firstErr error
+
+	for _, session := range srv.sessions.List() {
+		if !session.isListening(channel) {
+			continue
+		}
+
+		if err := session.notify(ctx, channel, payload); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("wire: notifying session %d: %w", session.ProcessID, err)
+		}
+	}
+
+	return firstErr
+}
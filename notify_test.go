@@ -0,0 +1,148 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSessionListenUnlisten(t *testing.T) {
+	session := &Session{ProcessID: 1}
+
+	if session.isListening("orders") {
+		t.Fatal("expected session not to be listening yet")
+	}
+
+	session.listen("orders")
+	if !session.isListening("orders") {
+		t.Fatal("expected session to be listening on orders")
+	}
+
+	session.unlisten("orders")
+	if session.isListening("orders") {
+		t.Fatal("expected session to no longer be listening on orders")
+	}
+}
+
+func TestServerBroadcastDeliversOnlyToListeners(t *testing.T) {
+	srv := &Server{sessions: NewSessions()}
+
+	listeningConn := newFakeSessionConn()
+	listening := &Session{ProcessID: 1, server: srv, conn: listeningConn}
+	listening.listen("orders")
+
+	idleConn := newFakeSessionConn()
+	idle := &Session{ProcessID: 2, server: srv, conn: idleConn}
+
+	srv.sessions.register(listening)
+	srv.sessions.register(idle)
+
+	if err := srv.Broadcast(context.Background(), "orders", "payload"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(listeningConn.notifications) != 1 {
+		t.Fatalf("expected the listening session to receive 1 notification, got %d", len(listeningConn.notifications))
+	}
+
+	got := listeningConn.notifications[0]
+	if got.channel != "orders" || got.payload != "payload" {
+		t.Fatalf("unexpected notification delivered: %+v", got)
+	}
+
+	if len(idleConn.notifications) != 0 {
+		t.Fatalf("expected the non-listening session to receive no notifications, got %d", len(idleConn.notifications))
+	}
+}
+
+func TestInterceptListenCommand(t *testing.T) {
+	srv := &Server{sessions: NewSessions()}
+	ctx := withSession(context.Background(), &Session{ProcessID: 1})
+
+	handled, err := srv.InterceptListenCommand(ctx, "LISTEN orders;")
+	if !handled || err != nil {
+		t.Fatalf("expected LISTEN to be handled without error, got handled=%v err=%v", handled, err)
+	}
+
+	if !CurrentSession(ctx).isListening("orders") {
+		t.Fatal("expected session to be listening on orders after LISTEN")
+	}
+
+	handled, err = srv.InterceptListenCommand(ctx, "UNLISTEN orders;")
+	if !handled || err != nil {
+		t.Fatalf("expected UNLISTEN to be handled without error, got handled=%v err=%v", handled, err)
+	}
+
+	if CurrentSession(ctx).isListening("orders") {
+		t.Fatal("expected session to no longer be listening on orders after UNLISTEN")
+	}
+
+	handled, err = srv.InterceptListenCommand(ctx, "SELECT 1")
+	if handled || err != nil {
+		t.Fatalf("expected a normal query to be left unhandled, got handled=%v err=%v", handled, err)
+	}
+}
+
+func TestInterceptListenCommandUnlistenAll(t *testing.T) {
+	srv := &Server{sessions: NewSessions()}
+	session := &Session{ProcessID: 1}
+	session.listen("orders")
+	session.listen("payments")
+	ctx := withSession(context.Background(), session)
+
+	handled, err := srv.InterceptListenCommand(ctx, "UNLISTEN *;")
+	if !handled || err != nil {
+		t.Fatalf("expected UNLISTEN * to be handled without error, got handled=%v err=%v", handled, err)
+	}
+
+	if len(session.listeningChannels()) != 0 {
+		t.Fatalf("expected all subscriptions to be removed, got %v", session.listeningChannels())
+	}
+}
+
+func TestInterceptListenCommandUnlistenAllConsultsListenHandler(t *testing.T) {
+	audited := make(map[string]bool)
+	srv := &Server{
+		sessions: NewSessions(),
+		listenHandler: func(ctx context.Context, channel string) error {
+			audited[channel] = true
+			return nil
+		},
+	}
+
+	session := &Session{ProcessID: 1}
+	session.listen("orders")
+	session.listen("payments")
+	ctx := withSession(context.Background(), session)
+
+	handled, err := srv.InterceptListenCommand(ctx, "UNLISTEN *;")
+	if !handled || err != nil {
+		t.Fatalf("expected UNLISTEN * to be handled without error, got handled=%v err=%v", handled, err)
+	}
+
+	if !audited["orders"] || !audited["payments"] {
+		t.Fatalf("expected the ListenHandler to be consulted for every channel, got %v", audited)
+	}
+}
+
+func TestInterceptListenCommandUnlistenAllStopsOnListenHandlerRejection(t *testing.T) {
+	srv := &Server{
+		sessions: NewSessions(),
+		listenHandler: func(ctx context.Context, channel string) error {
+			return fmt.Errorf("%s may not be unlistened", channel)
+		},
+	}
+
+	session := &Session{ProcessID: 1}
+	session.listen("orders")
+	ctx := withSession(context.Background(), session)
+
+	handled, err := srv.InterceptListenCommand(ctx, "UNLISTEN *;")
+	if !handled || err == nil {
+		t.Fatal("expected UNLISTEN * to be handled but rejected by the ListenHandler")
+	}
+
+	if !session.isListening("orders") {
+		t.Fatal("expected the subscription to survive a rejected unlisten")
+	}
+}
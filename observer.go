@@ -0,0 +1,168 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+)
+
+// EndReason classifies why a query's execution ended, distinguishing
+// client-initiated terminations from genuine handler failures the same way
+// HTTP services often carve out a synthetic 499 for "client gone".
+type EndReason int
+
+// Possible reasons a query ends, passed to Observer.QueryEnd.
+const (
+	// Completed means the handler returned normally and its result was
+	// sent to the client.
+	Completed EndReason = iota
+
+	// ClientCanceled means the query's context was canceled while the
+	// query was executing, as happens when a CancelRequest for this
+	// session's ProcessID is received. Every context.Canceled error is
+	// reported this way, including a cancellation made via Sessions.Kill.
+	ClientCanceled
+
+	// ClientDisconnected means the client's connection closed while a
+	// response was being written, so the query's outcome was never
+	// delivered.
+	ClientDisconnected
+
+	// Timeout means the query's context deadline elapsed before the
+	// handler returned.
+	Timeout
+
+	// HandlerError means the handler returned an error unrelated to
+	// cancellation, disconnection, or timeout.
+	HandlerError
+)
+
+// String returns a short, stable name for the reason, suitable for use as
+// a metric label.
+func (r EndReason) String() string {
+	switch r {
+	case Completed:
+		return "completed"
+	case ClientCanceled:
+		return "client_canceled"
+	case ClientDisconnected:
+		return "client_disconnected"
+	case Timeout:
+		return "timeout"
+	case HandlerError:
+		return "handler_error"
+	default:
+		return "unknown"
+	}
+}
+
+// Observer receives lifecycle events for every query the server executes.
+// Implementations must return quickly: QueryStart and QueryEnd are called
+// from the connection's read/write loop and block query execution while
+// they run.
+type Observer interface {
+	// QueryStart is invoked immediately before a handler begins executing
+	// sql.
+	QueryStart(ctx context.Context, sql string)
+
+	// QueryEnd is invoked once a query has finished, however it finished.
+	// rows is the number of rows written to the client before completion,
+	// err is the error returned by the handler (if any), and reason
+	// classifies why the query ended.
+	QueryEnd(ctx context.Context, sql string, rows int, err error, reason EndReason)
+}
+
+// WithObserver installs an Observer that receives a QueryStart/QueryEnd
+// pair for every query the server executes.
+func WithObserver(observer Observer) Option {
+	return func(srv *Server) {
+		srv.observer = observer
+	}
+}
+
+// classifyEndReason inspects the error returned while running or writing a
+// query's response and determines which EndReason best describes it. It is
+// used by the read/write loop so a broken pipe encountered mid-write is
+// reported as ClientDisconnected rather than a generic HandlerError, and a
+// context canceled by an incoming CancelRequest is reported as
+// ClientCanceled rather than a timeout.
+func classifyEndReason(err error) EndReason {
+	if err == nil {
+		return Completed
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ClientCanceled
+	}
+
+	if isBrokenConnection(err) {
+		return ClientDisconnected
+	}
+
+	return HandlerError
+}
+
+// isBrokenConnection reports whether err indicates the client's connection
+// went away mid-write (a broken pipe or connection reset), as opposed to a
+// handler-level failure.
+func isBrokenConnection(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	var netErr *net.OpError
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return false
+}
+
+// observeQueryStart notifies the server's Observer, if any, that sql has
+// begun executing. It is a no-op when no Observer is configured.
+func (srv *Server) observeQueryStart(ctx context.Context, sql string) {
+	if srv.observer != nil {
+		srv.observer.QueryStart(ctx, sql)
+	}
+}
+
+// observeQueryEnd notifies the server's Observer, if any, that sql has
+// finished executing. It is a no-op when no Observer is configured.
+func (srv *Server) observeQueryEnd(ctx context.Context, sql string, rows int, err error) {
+	if srv.observer == nil {
+		return
+	}
+
+	srv.observer.QueryEnd(ctx, sql, rows, err, classifyEndReason(err))
+}
+
+// ObserveQuery runs handle, reporting its start and end to the server's
+// configured Observer (see WithObserver). The connection's query-dispatch
+// path must call this around every handler invocation, passing the query's
+// text as sql and a handle that runs the actual handler and reports back
+// how many rows it wrote; this is the only call site that makes
+// QueryStart/QueryEnd fire for a real query. When no Observer is
+// configured, handle still runs but reporting is skipped entirely.
+//
+// While handle runs, sql is also recorded as the current query of ctx's
+// Session (see Session.Query), and cleared once handle returns.
+func (srv *Server) ObserveQuery(ctx context.Context, sql string, handle func(ctx context.Context) (rows int, err error)) error {
+	session := CurrentSession(ctx)
+	if session != nil {
+		session.setQuery(sql)
+	}
+
+	srv.observeQueryStart(ctx, sql)
+	rows, err := handle(ctx)
+	srv.observeQueryEnd(ctx, sql, rows, err)
+
+	if session != nil {
+		session.setQuery("")
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestClassifyEndReason(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want EndReason
+	}{
+		{"nil", nil, Completed},
+		{"deadline", context.DeadlineExceeded, Timeout},
+		{"canceled", context.Canceled, ClientCanceled},
+		{"eof", io.EOF, ClientDisconnected},
+		{"other", errors.New("boom"), HandlerError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyEndReason(tc.err); got != tc.want {
+				t.Fatalf("classifyEndReason(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEndReasonString(t *testing.T) {
+	if Timeout.String() != "timeout" {
+		t.Fatalf("unexpected string for Timeout: %q", Timeout.String())
+	}
+}
+
+type recordingObserver struct {
+	startedSQL string
+	endedSQL   string
+	rows       int
+	err        error
+	reason     EndReason
+}
+
+func (o *recordingObserver) QueryStart(ctx context.Context, sql string) {
+	o.startedSQL = sql
+}
+
+func (o *recordingObserver) QueryEnd(ctx context.Context, sql string, rows int, err error, reason EndReason) {
+	o.endedSQL = sql
+	o.rows = rows
+	o.err = err
+	o.reason = reason
+}
+
+func TestObserveQueryReportsStartAndEnd(t *testing.T) {
+	observer := &recordingObserver{}
+	srv := &Server{observer: observer}
+
+	err := srv.ObserveQuery(context.Background(), "SELECT 1", func(ctx context.Context) (int, error) {
+		return 3, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if observer.startedSQL != "SELECT 1" {
+		t.Fatalf("expected QueryStart to fire with the query text, got %q", observer.startedSQL)
+	}
+	if observer.endedSQL != "SELECT 1" || observer.rows != 3 || observer.reason != Completed {
+		t.Fatalf("unexpected QueryEnd: sql=%q rows=%d reason=%v", observer.endedSQL, observer.rows, observer.reason)
+	}
+}
+
+func TestObserveQueryClassifiesHandlerError(t *testing.T) {
+	observer := &recordingObserver{}
+	srv := &Server{observer: observer}
+	handlerErr := errors.New("boom")
+
+	err := srv.ObserveQuery(context.Background(), "SELECT 1", func(ctx context.Context) (int, error) {
+		return 0, handlerErr
+	})
+	if err != handlerErr {
+		t.Fatalf("expected ObserveQuery to propagate the handler's error, got %v", err)
+	}
+	if observer.reason != HandlerError {
+		t.Fatalf("expected HandlerError, got %v", observer.reason)
+	}
+}
+
+func TestObserveQueryWithoutObserverStillRunsHandle(t *testing.T) {
+	srv := &Server{}
+	called := false
+
+	err := srv.ObserveQuery(context.Background(), "SELECT 1", func(ctx context.Context) (int, error) {
+		called = true
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !called {
+		t.Fatal("expected handle to run even without a configured Observer")
+	}
+}
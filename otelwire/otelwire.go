@@ -0,0 +1,72 @@
+// Package otelwire adapts wire.Observer events into OpenTelemetry metrics,
+// so query duration and outcome can be tracked with the same instruments
+// used for the rest of a service.
+package otelwire
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	wire "github.com/hrl20/psql-wire"
+)
+
+// Observer records query duration, labeled by outcome, into an
+// OpenTelemetry histogram. Construct it with NewObserver and install it
+// with wire.WithObserver. Start times are keyed by the context.Context
+// identity QueryStart was called with, rather than the session's
+// ProcessID: QueryStart/QueryEnd are always called in pairs on the same
+// ctx for a given query, and keying on ctx avoids every connection
+// colliding on the same key when no Session is reachable (ProcessID 0).
+type Observer struct {
+	duration metric.Float64Histogram
+
+	mutex   sync.Mutex
+	started map[context.Context]time.Time
+}
+
+// NewObserver builds an Observer that records query durations, in seconds,
+// to a histogram named "psqlwire.query.duration" created from meter.
+func NewObserver(meter metric.Meter) (*Observer, error) {
+	duration, err := meter.Float64Histogram(
+		"psqlwire.query.duration",
+		metric.WithDescription("Duration of queries executed by the psql-wire server, in seconds."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Observer{duration: duration, started: make(map[context.Context]time.Time)}, nil
+}
+
+// QueryStart implements wire.Observer.
+func (o *Observer) QueryStart(ctx context.Context, sql string) {
+	o.mutex.Lock()
+	o.started[ctx] = time.Now()
+	o.mutex.Unlock()
+}
+
+// QueryEnd implements wire.Observer. It records the elapsed query duration
+// labeled with the outcome's EndReason, so dashboards can separate
+// legitimate latency from client cancellations and disconnects.
+func (o *Observer) QueryEnd(ctx context.Context, sql string, rows int, err error, reason wire.EndReason) {
+	o.mutex.Lock()
+	started, ok := o.started[ctx]
+	delete(o.started, ctx)
+	o.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	o.duration.Record(ctx, time.Since(started).Seconds(),
+		metric.WithAttributes(
+			attribute.String("reason", reason.String()),
+			attribute.Bool("error", err != nil),
+		),
+	)
+}
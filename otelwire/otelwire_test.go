@@ -0,0 +1,39 @@
+package otelwire
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric/noop"
+
+	wire "github.com/hrl20/psql-wire"
+)
+
+func TestObserverDoesNotCollideAcrossConcurrentQueriesWithNoSession(t *testing.T) {
+	observer, err := NewObserver(noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Neither ctx carries a Session, so both would previously be keyed by
+	// the same fallback ProcessID (0). QueryEnd for the first query must
+	// not be satisfied by, or clobber, the second query's start time.
+	type connKey struct{}
+	first := context.WithValue(context.Background(), connKey{}, "conn-1")
+	second := context.WithValue(context.Background(), connKey{}, "conn-2")
+
+	observer.QueryStart(first, "SELECT 1")
+	observer.QueryStart(second, "SELECT 2")
+
+	observer.QueryEnd(first, "SELECT 1", 1, nil, wire.Completed)
+
+	if _, ok := observer.started[second]; !ok {
+		t.Fatal("expected the second query's start time to survive the first query's end")
+	}
+
+	observer.QueryEnd(second, "SELECT 2", 1, nil, wire.Completed)
+
+	if len(observer.started) != 0 {
+		t.Fatalf("expected no start times to remain after both queries ended, got %d", len(observer.started))
+	}
+}
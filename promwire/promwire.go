@@ -0,0 +1,70 @@
+// Package promwire adapts wire.Observer events into a Prometheus
+// histogram, for deployments that scrape metrics directly rather than
+// going through OpenTelemetry.
+package promwire
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	wire "github.com/hrl20/psql-wire"
+)
+
+// Observer records query duration into a Prometheus histogram vector
+// labeled by outcome reason. Construct it with NewObserver and install it
+// with wire.WithObserver. Start times are keyed by the context.Context
+// identity QueryStart was called with, rather than the session's
+// ProcessID: QueryStart/QueryEnd are always called in pairs on the same
+// ctx for a given query, and keying on ctx avoids every connection
+// colliding on the same key when no Session is reachable (ProcessID 0).
+type Observer struct {
+	duration *prometheus.HistogramVec
+
+	mutex   sync.Mutex
+	started map[context.Context]time.Time
+}
+
+// NewObserver builds an Observer and registers its histogram,
+// "psqlwire_query_duration_seconds", with reg.
+func NewObserver(reg prometheus.Registerer) (*Observer, error) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "psqlwire_query_duration_seconds",
+		Help:    "Duration of queries executed by the psql-wire server, in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"reason", "error"})
+
+	if err := reg.Register(duration); err != nil {
+		return nil, err
+	}
+
+	return &Observer{duration: duration, started: make(map[context.Context]time.Time)}, nil
+}
+
+// QueryStart implements wire.Observer.
+func (o *Observer) QueryStart(ctx context.Context, sql string) {
+	o.mutex.Lock()
+	o.started[ctx] = time.Now()
+	o.mutex.Unlock()
+}
+
+// QueryEnd implements wire.Observer.
+func (o *Observer) QueryEnd(ctx context.Context, sql string, rows int, err error, reason wire.EndReason) {
+	o.mutex.Lock()
+	started, ok := o.started[ctx]
+	delete(o.started, ctx)
+	o.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	errLabel := "false"
+	if err != nil {
+		errLabel = "true"
+	}
+
+	o.duration.WithLabelValues(reason.String(), errLabel).Observe(time.Since(started).Seconds())
+}
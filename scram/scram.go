@@ -0,0 +1,285 @@
+// Package scram implements the SCRAM-SHA-256 and SCRAM-SHA-256-PLUS SASL
+// mechanisms (RFC 5802, RFC 7677) as a wire.Authenticator, so a psql-wire
+// server can authenticate clients without ever seeing a plaintext
+// password.
+package scram
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/hrl20/psql-wire"
+)
+
+const (
+	mechanismSHA256     = "SCRAM-SHA-256"
+	mechanismSHA256Plus = "SCRAM-SHA-256-PLUS"
+
+	gs2HeaderNoBinding   = "n,,"
+	gs2HeaderWithBinding = "p=tls-server-end-point,,"
+)
+
+// StoredCredentials is the verifier material a UserLookup returns for a
+// username. It never includes the client's plaintext password: only the
+// derived keys SCRAM needs to verify a client-supplied proof.
+type StoredCredentials struct {
+	StoredKey  []byte
+	ServerKey  []byte
+	Salt       []byte
+	Iterations int
+}
+
+// UserLookup resolves a username to the SCRAM verifier material registered
+// for it. Implementations typically look this up from wherever the server
+// stores user credentials; see NewCredentials to derive one from a
+// plaintext password at registration time.
+type UserLookup func(ctx context.Context, username string) (StoredCredentials, error)
+
+// NewCredentials derives the StoredCredentials for a plaintext password,
+// for use when provisioning a user. iterations should be at least 4096
+// per RFC 5802's recommended minimum.
+func NewCredentials(password string, salt []byte, iterations int) StoredCredentials {
+	saltedPassword := pbkdf2SHA256(password, salt, iterations)
+	clientKey := hmacSHA256(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := hmacSHA256(saltedPassword, []byte("Server Key"))
+
+	return StoredCredentials{
+		StoredKey:  storedKey[:],
+		ServerKey:  serverKey,
+		Salt:       salt,
+		Iterations: iterations,
+	}
+}
+
+type authenticator struct {
+	lookup UserLookup
+}
+
+// New returns a wire.Authenticator implementing SCRAM-SHA-256 and
+// SCRAM-SHA-256-PLUS (when the connection is over TLS), backed by lookup
+// to resolve each username's verifier material.
+func New(lookup UserLookup) wire.Authenticator {
+	return &authenticator{lookup: lookup}
+}
+
+func (a *authenticator) Mechanisms() []string {
+	return []string{mechanismSHA256Plus, mechanismSHA256}
+}
+
+// withoutChannelBinding drops SCRAM-SHA-256-PLUS from mechanisms, for
+// connections that have no channel binding data to back it (i.e. not
+// using TLS).
+func withoutChannelBinding(mechanisms []string) []string {
+	filtered := make([]string, 0, len(mechanisms))
+	for _, mechanism := range mechanisms {
+		if mechanism != mechanismSHA256Plus {
+			filtered = append(filtered, mechanism)
+		}
+	}
+	return filtered
+}
+
+func (a *authenticator) Authenticate(ctx context.Context, user string, exchange wire.SASLExchange) error {
+	creds, err := a.lookup(ctx, user)
+	if err != nil {
+		return fmt.Errorf("scram: looking up credentials for %q: %w", user, err)
+	}
+
+	channelBinding, hasBinding := exchange.ChannelBinding()
+
+	mechanisms := a.Mechanisms()
+	if !hasBinding {
+		mechanisms = withoutChannelBinding(mechanisms)
+	}
+
+	clientFirst, err := exchange.InitialResponse(mechanisms)
+	if err != nil {
+		return fmt.Errorf("scram: reading client-first-message: %w", err)
+	}
+
+	gs2Header, clientFirstBare, clientNonce, err := parseClientFirstMessage(clientFirst)
+	if err != nil {
+		return fmt.Errorf("scram: %w", err)
+	}
+
+	usesBinding := strings.HasPrefix(gs2Header, "p=")
+	if usesBinding && !hasBinding {
+		return fmt.Errorf("scram: client requested channel binding over a non-TLS connection")
+	}
+
+	serverNonce := clientNonce + generateNonce()
+	serverFirst := fmt.Sprintf("r=%s,s=%s,i=%d",
+		serverNonce, base64.StdEncoding.EncodeToString(creds.Salt), creds.Iterations)
+
+	clientFinal, err := exchange.Continue([]byte(serverFirst))
+	if err != nil {
+		return fmt.Errorf("scram: reading client-final-message: %w", err)
+	}
+
+	channelBindingInput, finalNonce, proof, err := parseClientFinalMessage(clientFinal)
+	if err != nil {
+		return fmt.Errorf("scram: %w", err)
+	}
+
+	if finalNonce != serverNonce {
+		return fmt.Errorf("scram: nonce mismatch")
+	}
+
+	expectedBindingInput := base64.StdEncoding.EncodeToString([]byte(gs2Header))
+	if usesBinding {
+		expectedBindingInput = base64.StdEncoding.EncodeToString(append([]byte(gs2Header), channelBinding...))
+	}
+	if channelBindingInput != expectedBindingInput {
+		return fmt.Errorf("scram: channel binding mismatch")
+	}
+
+	authMessage := strings.Join([]string{clientFirstBare, serverFirst, withoutProof(clientFinal)}, ",")
+
+	clientSignature := hmacSHA256(creds.StoredKey, []byte(authMessage))
+	clientKey := xorBytes(proof, clientSignature)
+
+	computedStoredKey := sha256.Sum256(clientKey)
+	if !hmac.Equal(computedStoredKey[:], creds.StoredKey) {
+		return fmt.Errorf("scram: invalid client proof")
+	}
+
+	serverSignature := hmacSHA256(creds.ServerKey, []byte(authMessage))
+	serverFinal := fmt.Sprintf("v=%s", base64.StdEncoding.EncodeToString(serverSignature))
+
+	return exchange.Final([]byte(serverFinal))
+}
+
+func withoutProof(clientFinal []byte) string {
+	msg := string(clientFinal)
+	idx := strings.LastIndex(msg, ",p=")
+	if idx < 0 {
+		return msg
+	}
+	return msg[:idx]
+}
+
+func parseClientFirstMessage(msg []byte) (gs2Header, bare, nonce string, err error) {
+	s := string(msg)
+
+	commaIdx := strings.Index(s, ",")
+	if commaIdx < 0 {
+		return "", "", "", fmt.Errorf("malformed client-first-message")
+	}
+
+	// GS2 header is "n,," or "p=...,," or "y,,"; the bare message starts
+	// after the second comma.
+	rest := s[commaIdx+1:]
+	second := strings.Index(rest, ",")
+	if second < 0 {
+		return "", "", "", fmt.Errorf("malformed client-first-message")
+	}
+
+	gs2Header = s[:commaIdx+1+second+1]
+	bare = rest[second+1:]
+
+	attrs, err := parseAttributes(bare)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	nonce, ok := attrs["r"]
+	if !ok {
+		return "", "", "", fmt.Errorf("client-first-message missing nonce")
+	}
+
+	return gs2Header, bare, nonce, nil
+}
+
+func parseClientFinalMessage(msg []byte) (channelBinding, nonce string, proof []byte, err error) {
+	attrs, err := parseAttributes(string(msg))
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	channelBinding, ok := attrs["c"]
+	if !ok {
+		return "", "", nil, fmt.Errorf("client-final-message missing channel binding")
+	}
+
+	nonce, ok = attrs["r"]
+	if !ok {
+		return "", "", nil, fmt.Errorf("client-final-message missing nonce")
+	}
+
+	encodedProof, ok := attrs["p"]
+	if !ok {
+		return "", "", nil, fmt.Errorf("client-final-message missing proof")
+	}
+
+	proof, err = base64.StdEncoding.DecodeString(encodedProof)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid client proof encoding: %w", err)
+	}
+
+	return channelBinding, nonce, proof, nil
+}
+
+func parseAttributes(s string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed attribute %q", part)
+		}
+		attrs[kv[0]] = kv[1]
+	}
+	return attrs, nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func pbkdf2SHA256(password string, salt []byte, iterations int) []byte {
+	// RFC 5802 Hi(): U1 = HMAC(password, salt || 1), Ui = HMAC(password, Ui-1),
+	// result = U1 XOR U2 XOR ... XOR Ui.
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+
+	result := make([]byte, len(u))
+	copy(result, u)
+
+	for i := 1; i < iterations; i++ {
+		u = hmacSHA256([]byte(password), u)
+		result = xorBytes(result, u)
+	}
+
+	return result
+}
+
+func xorBytes(a, b []byte) []byte {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	out := make([]byte, n)
+	for i := 0; i < n; i++ {
+		out[i] = a[i] ^ b[i]
+	}
+
+	return out
+}
+
+func generateNonce() string {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("scram: reading random nonce: %v", err))
+	}
+	return base64.RawStdEncoding.EncodeToString(buf)
+}
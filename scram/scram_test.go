@@ -0,0 +1,171 @@
+package scram
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeClientExchange plays the client side of a SCRAM-SHA-256 handshake
+// against an authenticator under test, so the server implementation can be
+// exercised end-to-end without a real network connection.
+type fakeClientExchange struct {
+	password       string
+	clientNonce    string
+	clientFirst    string
+	authMessage    string
+	saltedPwd      []byte
+	offered        []string
+	channelBinding []byte
+	bindingData    bool
+}
+
+func (f *fakeClientExchange) ChannelBinding() ([]byte, bool) {
+	return f.channelBinding, f.bindingData
+}
+
+func (f *fakeClientExchange) InitialResponse(mechanisms []string) ([]byte, error) {
+	f.offered = mechanisms
+	f.clientNonce = "fixed-test-nonce"
+	bare := fmt.Sprintf("n=tester,r=%s", f.clientNonce)
+	f.clientFirst = bare
+
+	gs2Header := "n,,"
+	if f.bindingData {
+		gs2Header = "p=tls-server-end-point,,"
+	}
+	return []byte(gs2Header + bare), nil
+}
+
+func (f *fakeClientExchange) Continue(challenge []byte) ([]byte, error) {
+	attrs := mustParseAttrs(string(challenge))
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, err
+	}
+
+	var iterations int
+	fmt.Sscanf(attrs["i"], "%d", &iterations)
+
+	f.saltedPwd = pbkdf2SHA256(f.password, salt, iterations)
+	clientKey := hmacSHA256(f.saltedPwd, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+
+	gs2Header := "n,,"
+	bindingInput := []byte(gs2Header)
+	if f.bindingData {
+		gs2Header = "p=tls-server-end-point,,"
+		bindingInput = append([]byte(gs2Header), f.channelBinding...)
+	}
+
+	withoutProof := fmt.Sprintf("c=%s,r=%s", base64.StdEncoding.EncodeToString(bindingInput), attrs["r"])
+	f.authMessage = strings.Join([]string{f.clientFirst, string(challenge), withoutProof}, ",")
+
+	clientSignature := hmacSHA256(storedKey[:], []byte(f.authMessage))
+	proof := xorBytes(clientKey, clientSignature)
+
+	final := fmt.Sprintf("%s,p=%s", withoutProof, base64.StdEncoding.EncodeToString(proof))
+	return []byte(final), nil
+}
+
+func (f *fakeClientExchange) Final(data []byte) error {
+	attrs := mustParseAttrs(string(data))
+	serverKey := hmacSHA256(f.saltedPwd, []byte("Server Key"))
+	expected := hmacSHA256(serverKey, []byte(f.authMessage))
+
+	got, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(got, expected) {
+		return fmt.Errorf("server signature mismatch")
+	}
+
+	return nil
+}
+
+func mustParseAttrs(s string) map[string]string {
+	attrs, err := parseAttributes(s)
+	if err != nil {
+		panic(err)
+	}
+	return attrs
+}
+
+func TestSCRAMAuthenticateSuccess(t *testing.T) {
+	salt := []byte("fixedsalt")
+	creds := NewCredentials("correct-horse", salt, 4096)
+
+	auth := New(func(ctx context.Context, username string) (StoredCredentials, error) {
+		if username != "tester" {
+			return StoredCredentials{}, fmt.Errorf("unknown user %q", username)
+		}
+		return creds, nil
+	})
+
+	client := &fakeClientExchange{password: "correct-horse"}
+	if err := auth.Authenticate(context.Background(), "tester", client); err != nil {
+		t.Fatalf("expected authentication to succeed, got: %v", err)
+	}
+}
+
+func TestSCRAMAuthenticateOffersPlusOnlyWithChannelBinding(t *testing.T) {
+	salt := []byte("fixedsalt")
+	creds := NewCredentials("correct-horse", salt, 4096)
+
+	auth := New(func(ctx context.Context, username string) (StoredCredentials, error) {
+		return creds, nil
+	})
+
+	client := &fakeClientExchange{password: "correct-horse"}
+	if err := auth.Authenticate(context.Background(), "tester", client); err != nil {
+		t.Fatalf("expected authentication to succeed, got: %v", err)
+	}
+
+	want := []string{mechanismSHA256}
+	if len(client.offered) != len(want) || client.offered[0] != want[0] {
+		t.Fatalf("expected only %v to be offered without channel binding, got %v", want, client.offered)
+	}
+}
+
+func TestSCRAMAuthenticateWithChannelBindingSucceeds(t *testing.T) {
+	salt := []byte("fixedsalt")
+	creds := NewCredentials("correct-horse", salt, 4096)
+
+	auth := New(func(ctx context.Context, username string) (StoredCredentials, error) {
+		return creds, nil
+	})
+
+	client := &fakeClientExchange{
+		password:       "correct-horse",
+		bindingData:    true,
+		channelBinding: []byte("fixed-tls-binding"),
+	}
+	if err := auth.Authenticate(context.Background(), "tester", client); err != nil {
+		t.Fatalf("expected a channel-bound authentication to succeed, got: %v", err)
+	}
+
+	want := []string{mechanismSHA256Plus, mechanismSHA256}
+	if len(client.offered) != len(want) || client.offered[0] != want[0] || client.offered[1] != want[1] {
+		t.Fatalf("expected both mechanisms to be offered with channel binding available, got %v", client.offered)
+	}
+}
+
+func TestSCRAMAuthenticateWrongPassword(t *testing.T) {
+	salt := []byte("fixedsalt")
+	creds := NewCredentials("correct-horse", salt, 4096)
+
+	auth := New(func(ctx context.Context, username string) (StoredCredentials, error) {
+		return creds, nil
+	})
+
+	client := &fakeClientExchange{password: "wrong-password"}
+	if err := auth.Authenticate(context.Background(), "tester", client); err == nil {
+		t.Fatal("expected authentication to fail with the wrong password")
+	}
+}
@@ -0,0 +1,297 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// TransactionStatus reflects the transaction state of a session, mirroring
+// the status byte Postgres reports in ReadyForQuery ('I', 'T', 'E').
+type TransactionStatus byte
+
+// Transaction status values as defined by the Postgres wire protocol.
+const (
+	TransactionStatusIdle   TransactionStatus = 'I'
+	TransactionStatusInTx   TransactionStatus = 'T'
+	TransactionStatusFailed TransactionStatus = 'E'
+)
+
+// Session represents a single client connection as tracked by the server,
+// analogous to a row in Postgres' pg_stat_activity view.
+type Session struct {
+	ProcessID   int32
+	SecretKey   int32
+	RemoteAddr  net.Addr
+	Username    string
+	Database    string
+	ConnectedAt time.Time
+	Cancel      context.CancelFunc
+
+	mutex     sync.RWMutex
+	query     string
+	status    TransactionStatus
+	server    *Server
+	listening map[string]struct{}
+	conn      sessionConn
+}
+
+// sessionConn is the subset of the underlying connection that
+// session-scoped features (NOTIFY, COPY) need in order to talk back to the
+// client out-of-band from the normal query response. It is satisfied by
+// the server's real connection type; defining it here, scoped to just
+// these methods, lets session-level features be exercised in tests
+// against a fake without needing a live connection.
+type sessionConn interface {
+	writeNotificationResponse(pid int32, channel, payload string) error
+	writeCopyOutResponse(format CopyFormat, columns int) error
+	writeCopyInResponse(format CopyFormat, columns int) error
+	writeCopyData(buf []byte) error
+	writeCopyDone() error
+	writeCopyFail(message string) error
+	readCopyMessage() (byte, []byte, error)
+}
+
+// Query returns the text of the query currently being executed by this
+// session, or the empty string if the session is idle.
+func (s *Session) Query() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.query
+}
+
+// Status returns the transaction status last recorded for the session.
+// Sessions created by NewConnContext or the default BackendKeyData hook
+// start out as TransactionStatusIdle, and nothing in this package changes
+// the status after that.
+func (s *Session) Status() TransactionStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.status
+}
+
+func (s *Session) setQuery(query string) {
+	s.mutex.Lock()
+	s.query = query
+	s.mutex.Unlock()
+}
+
+func (s *Session) setStatus(status TransactionStatus) {
+	s.mutex.Lock()
+	s.status = status
+	s.mutex.Unlock()
+}
+
+func (s *Session) listen(channel string) {
+	s.mutex.Lock()
+	if s.listening == nil {
+		s.listening = make(map[string]struct{})
+	}
+	s.listening[channel] = struct{}{}
+	s.mutex.Unlock()
+}
+
+func (s *Session) unlisten(channel string) {
+	s.mutex.Lock()
+	delete(s.listening, channel)
+	s.mutex.Unlock()
+}
+
+// listeningChannels returns a snapshot of the channels this session is
+// currently listening on.
+func (s *Session) listeningChannels() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	channels := make([]string, 0, len(s.listening))
+	for channel := range s.listening {
+		channels = append(channels, channel)
+	}
+
+	return channels
+}
+
+func (s *Session) isListening(channel string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.listening[channel]
+	return ok
+}
+
+// notify sends a NotificationResponse to this session's client. The write
+// is serialized against the connection's normal write path so it can only
+// ever be interleaved at a safe message boundary, never inside a partially
+// written row or response.
+func (s *Session) notify(ctx context.Context, channel, payload string) error {
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.writeNotificationResponse(s.ProcessID, channel, payload)
+}
+
+// Sessions is a registry of the connections currently known to a Server. It
+// is the equivalent of Postgres' pg_stat_activity: handlers and operators
+// can list active sessions, look one up by its backend ProcessID, or kill it.
+//
+// A Sessions registry is safe for concurrent use.
+type Sessions struct {
+	mutex    sync.RWMutex
+	sessions map[int32]*Session
+}
+
+// NewSessions constructs an empty session registry.
+func NewSessions() *Sessions {
+	return &Sessions{
+		sessions: make(map[int32]*Session),
+	}
+}
+
+// List returns a snapshot of all sessions currently tracked by the registry.
+func (s *Sessions) List() []*Session {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		result = append(result, session)
+	}
+
+	return result
+}
+
+// Get looks up the session owned by the given backend ProcessID. The second
+// return value reports whether a matching session was found.
+func (s *Sessions) Get(pid int32) (*Session, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	session, ok := s.sessions[pid]
+	return session, ok
+}
+
+// Kill terminates the session owned by the given ProcessID by cancelling its
+// query context, mirroring pg_terminate_backend. It returns an error if no
+// such session is registered.
+func (s *Sessions) Kill(pid int32) error {
+	s.mutex.RLock()
+	session, ok := s.sessions[pid]
+	s.mutex.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("wire: no session registered for process %d", pid)
+	}
+
+	if session.Cancel != nil {
+		session.Cancel()
+	}
+
+	return nil
+}
+
+func (s *Sessions) register(session *Session) {
+	s.mutex.Lock()
+	s.sessions[session.ProcessID] = session
+	s.mutex.Unlock()
+}
+
+func (s *Sessions) unregister(pid int32) {
+	s.mutex.Lock()
+	delete(s.sessions, pid)
+	s.mutex.Unlock()
+}
+
+func (s *Sessions) lookup(pid, secret int32) (*Session, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	session, ok := s.sessions[pid]
+	if !ok || session.SecretKey != secret {
+		return nil, false
+	}
+
+	return session, true
+}
+
+// sessionContextKey is the context key under which the active Session is
+// stored for the lifetime of a connection.
+type sessionContextKey struct{}
+
+// connSessions indexes Sessions by their connection's remote address, as a
+// fallback for CurrentSession when ctx carries no Session value. It exists
+// because BackendKeyData's hook signature (func(ctx) (int32, int32)) has
+// no way to hand a modified context back to whatever called it, so a
+// connection's startup path may never have had the chance to call
+// NewConnContext before a later handler call needs CurrentSession to
+// resolve. RemoteAddress(ctx) is still reachable at that point (it reads
+// off the connection itself, not a value NewConnContext would have
+// attached), and is unique for the life of one real connection, so
+// defaultBackendKeyData indexes by it as the connection's durable handle.
+var connSessions sync.Map // net.Addr.String() -> *Session
+
+func registerConnSession(addr net.Addr, session *Session) {
+	if addr == nil {
+		return
+	}
+	connSessions.Store(addr.String(), session)
+}
+
+func unregisterConnSession(addr net.Addr) {
+	if addr == nil {
+		return
+	}
+	connSessions.Delete(addr.String())
+}
+
+func lookupConnSession(addr net.Addr) (*Session, bool) {
+	if addr == nil {
+		return nil, false
+	}
+
+	session, ok := connSessions.Load(addr.String())
+	if !ok {
+		return nil, false
+	}
+
+	return session.(*Session), true
+}
+
+// CurrentSession returns the Session associated with the given context, or
+// nil if ctx was not derived from a connection handled by this package. It
+// first looks for a Session attached via NewConnContext, then falls back
+// to resolving one by the connection's remote address (see connSessions)
+// so a handler still reaches the right Session even when the connection's
+// startup path never called NewConnContext.
+func CurrentSession(ctx context.Context) *Session {
+	if session, ok := ctx.Value(sessionContextKey{}).(*Session); ok {
+		return session
+	}
+
+	session, ok := lookupConnSession(RemoteAddress(ctx))
+	if !ok {
+		return nil
+	}
+
+	return session
+}
+
+func withSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// NewConnContext attaches a blank Session to ctx and returns the derived
+// context. Calling it from the connection's startup path, before the
+// server's BackendKeyData hook runs, is the most direct way to make that
+// Session reachable via CurrentSession for the rest of the connection: the
+// default hook fills in the Session's fields in place rather than having
+// to create one of its own. It is not required for correctness, though,
+// since defaultBackendKeyData also indexes the Session by remote address
+// as a fallback (see connSessions) — but calling it keeps CurrentSession
+// working by ctx lineage alone, without depending on RemoteAddress(ctx)
+// having a live connection behind it, which is what lets tests in this
+// package exercise Sessions without a real net.Conn.
+func NewConnContext(ctx context.Context) context.Context {
+	return withSession(ctx, &Session{status: TransactionStatusIdle})
+}
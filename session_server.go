@@ -0,0 +1,92 @@
+package wire
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Sessions returns the registry of connections currently being served. It
+// is populated automatically by the server's default BackendKeyData hook,
+// consulted by its default CancelRequest hook, and is safe to query
+// concurrently from a handler.
+func (srv *Server) Sessions() *Sessions {
+	return srv.sessions
+}
+
+// defaultBackendKeyData is installed as the server's BackendKeyData hook
+// unless the caller supplies their own via the BackendKeyData option. It
+// fills in and registers the Session that NewConnContext attached to ctx
+// earlier in the connection's startup (or a freshly created one, if it
+// wasn't), so it becomes reachable through CurrentSession for the rest of
+// the connection's lifetime — both through ctx, and through the
+// connection's remote address, which is what lets CurrentSession still
+// resolve a Session for handlers further down the connection even when
+// ctx itself carries no Session value.
+//
+// It also arranges for the Session to be released automatically once ctx
+// is done, so a connection that closes without its teardown path calling
+// EndConn still doesn't leak a Sessions registry entry.
+func (srv *Server) defaultBackendKeyData(ctx context.Context) (int32, int32) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	processID, secretKey := rng.Int31(), rng.Int31()
+
+	session := CurrentSession(ctx)
+	if session == nil {
+		session = &Session{status: TransactionStatusIdle}
+	}
+
+	session.ProcessID = processID
+	session.SecretKey = secretKey
+	session.RemoteAddr = RemoteAddress(ctx)
+	session.ConnectedAt = time.Now()
+	session.server = srv
+
+	srv.sessions.register(session)
+	registerConnSession(session.RemoteAddr, session)
+
+	meta := SessionMeta{SecretKey: secretKey, NodeAddr: srv.nodeAddr}
+	_ = srv.sessionStoreOrDefault().Put(ctx, SessionKey(processID), meta)
+
+	go func() {
+		<-ctx.Done()
+		srv.endSession(session)
+	}()
+
+	return processID, secretKey
+}
+
+// endSession removes session from the Sessions registry, the remote
+// address index, and the configured SessionStore (if any). It is the
+// shared cleanup used both by EndConn and by the goroutine
+// defaultBackendKeyData starts to release a Session once its connection's
+// context is done.
+func (srv *Server) endSession(session *Session) {
+	if session == nil {
+		return
+	}
+
+	srv.sessions.unregister(session.ProcessID)
+	unregisterConnSession(session.RemoteAddr)
+
+	_ = srv.sessionStoreOrDefault().Delete(context.Background(), SessionKey(session.ProcessID))
+}
+
+// EndConn releases the Session associated with ctx, removing it from the
+// Sessions registry and from the configured SessionStore (if any).
+// defaultBackendKeyData already arranges for this to happen automatically
+// once ctx is done, so calling EndConn explicitly from the connection's
+// teardown path is only needed when a caller wants the cleanup to happen
+// before ctx itself is canceled.
+func (srv *Server) EndConn(ctx context.Context) {
+	srv.endSession(CurrentSession(ctx))
+}
+
+// defaultCancelRequest is installed as the server's CancelRequest hook
+// unless the caller supplies their own via the CancelRequest option. It
+// delegates to routeCancelRequest so a session owned by another node (as
+// resolved through the configured SessionStore) is forwarded via
+// CrossNodeCancel instead of being silently dropped.
+func (srv *Server) defaultCancelRequest(ctx context.Context, processID, secretKey int32) error {
+	return srv.routeCancelRequest(ctx, SessionKey(processID), secretKey)
+}
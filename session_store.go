@@ -0,0 +1,155 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// SessionKey identifies a session across the cluster by its backend
+// ProcessID, the same identifier Postgres clients send back in a
+// CancelRequest.
+type SessionKey int32
+
+// SessionMeta is the cluster-wide metadata a SessionStore keeps about a
+// session so that a CancelRequest arriving on any node can be routed to
+// whichever node actually owns the query.
+type SessionMeta struct {
+	SecretKey int32
+	NodeAddr  string
+}
+
+// SessionStore is implemented by types that can publish and resolve session
+// ownership across nodes. The default, in-memory implementation only knows
+// about sessions local to the current process; plugging in a shared store
+// (Redis, etcd, ...) via WithSessionStore lets CancelRequest work correctly
+// behind a load balancer fronting more than one server process.
+type SessionStore interface {
+	Put(ctx context.Context, key SessionKey, meta SessionMeta) error
+	Lookup(ctx context.Context, key SessionKey) (SessionMeta, error)
+	Delete(ctx context.Context, key SessionKey) error
+}
+
+// ErrSessionNotFound is returned by a SessionStore when no metadata is
+// registered for the requested key.
+var ErrSessionNotFound = fmt.Errorf("wire: session not found")
+
+// memorySessionStore is the SessionStore used when no store is configured
+// via WithSessionStore. It keeps metadata in process memory only, which
+// matches the server's pre-existing, single-node behavior.
+type memorySessionStore struct {
+	mutex sync.RWMutex
+	data  map[SessionKey]SessionMeta
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{data: make(map[SessionKey]SessionMeta)}
+}
+
+func (s *memorySessionStore) Put(_ context.Context, key SessionKey, meta SessionMeta) error {
+	s.mutex.Lock()
+	s.data[key] = meta
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *memorySessionStore) Lookup(_ context.Context, key SessionKey) (SessionMeta, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	meta, ok := s.data[key]
+	if !ok {
+		return SessionMeta{}, ErrSessionNotFound
+	}
+
+	return meta, nil
+}
+
+func (s *memorySessionStore) Delete(_ context.Context, key SessionKey) error {
+	s.mutex.Lock()
+	delete(s.data, key)
+	s.mutex.Unlock()
+	return nil
+}
+
+// CrossNodeCancel is invoked when a CancelRequest targets a session owned by
+// a different node, as resolved through the configured SessionStore. Users
+// implement this to forward the cancellation over whatever internal RPC
+// mechanism connects their nodes (e.g. gRPC, an HTTP admin endpoint, a
+// message bus).
+type CrossNodeCancel func(ctx context.Context, meta SessionMeta, key SessionKey, secretKey int32) error
+
+// WithNodeAddr sets the address other nodes should use to reach this server
+// when forwarding a cross-node CancelRequest via CrossNodeCancel. It should
+// be set whenever WithSessionStore is used in a multi-node deployment.
+func WithNodeAddr(addr string) Option {
+	return func(srv *Server) {
+		srv.nodeAddr = addr
+	}
+}
+
+// WithSessionStore configures the SessionStore used to resolve which node
+// owns a session, enabling CancelRequest to work correctly in horizontally
+// scaled deployments sitting behind a TCP load balancer. It defaults to an
+// in-memory store scoped to the current process.
+func WithSessionStore(store SessionStore) Option {
+	return func(srv *Server) {
+		srv.sessionStore = store
+	}
+}
+
+// sessionStoreOrDefault returns the server's configured SessionStore,
+// lazily initializing it to an in-memory store scoped to this process if
+// WithSessionStore was never used, so a Server built without that option
+// still publishes and resolves sessions through the in-memory default that
+// WithSessionStore describes.
+func (srv *Server) sessionStoreOrDefault() SessionStore {
+	srv.sessionStoreOnce.Do(func() {
+		if srv.sessionStore == nil {
+			srv.sessionStore = newMemorySessionStore()
+		}
+	})
+
+	return srv.sessionStore
+}
+
+// WithCrossNodeCancel configures the callback used to forward a
+// CancelRequest to the node that owns the target session, as resolved via
+// the configured SessionStore. Without this option, cancel requests for
+// sessions not owned by the local node are silently ignored, matching
+// Postgres' behavior of dropping cancel requests it cannot satisfy.
+func WithCrossNodeCancel(fn CrossNodeCancel) Option {
+	return func(srv *Server) {
+		srv.crossNodeCancel = fn
+	}
+}
+
+// routeCancelRequest resolves the owner of the given session key through the
+// server's SessionStore and either cancels it locally or forwards the
+// request via CrossNodeCancel when it belongs to another node.
+func (srv *Server) routeCancelRequest(ctx context.Context, key SessionKey, secretKey int32) error {
+	if session, ok := srv.sessions.lookup(int32(key), secretKey); ok {
+		if session.Cancel != nil {
+			session.Cancel()
+		}
+		return nil
+	}
+
+	meta, err := srv.sessionStoreOrDefault().Lookup(ctx, key)
+	if err != nil {
+		if err == ErrSessionNotFound {
+			return nil
+		}
+		return fmt.Errorf("wire: looking up session owner: %w", err)
+	}
+
+	if meta.SecretKey != secretKey {
+		return nil
+	}
+
+	if srv.crossNodeCancel == nil {
+		return nil
+	}
+
+	return srv.crossNodeCancel(ctx, meta, key, secretKey)
+}
@@ -0,0 +1,112 @@
+package wire
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	store := newMemorySessionStore()
+	ctx := context.Background()
+
+	key := SessionKey(7)
+	meta := SessionMeta{SecretKey: 99, NodeAddr: "node-a:5432"}
+
+	if err := store.Put(ctx, key, meta); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := store.Lookup(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != meta {
+		t.Fatalf("expected %+v, got %+v", meta, got)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.Lookup(ctx, key); err != ErrSessionNotFound {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestDefaultCancelRequestForwardsCrossNode(t *testing.T) {
+	store := newMemorySessionStore()
+	remoteMeta := SessionMeta{SecretKey: 7, NodeAddr: "node-b:5432"}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, SessionKey(99), remoteMeta); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var forwarded SessionMeta
+	var forwardedKey SessionKey
+
+	srv := &Server{
+		sessions:     NewSessions(),
+		sessionStore: store,
+		crossNodeCancel: func(ctx context.Context, meta SessionMeta, key SessionKey, secretKey int32) error {
+			forwarded = meta
+			forwardedKey = key
+			return nil
+		},
+	}
+
+	// 99 is not owned by this node's local Sessions registry, so the
+	// default hook must fall through to the SessionStore and forward via
+	// CrossNodeCancel rather than silently dropping the request.
+	if err := srv.defaultCancelRequest(ctx, 99, 7); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if forwarded != remoteMeta {
+		t.Fatalf("expected CrossNodeCancel to receive %+v, got %+v", remoteMeta, forwarded)
+	}
+
+	if forwardedKey != SessionKey(99) {
+		t.Fatalf("expected CrossNodeCancel to receive key 99, got %d", forwardedKey)
+	}
+}
+
+func TestSessionStoreOrDefaultInitializesInMemoryStore(t *testing.T) {
+	srv := &Server{sessions: NewSessions()}
+
+	if srv.sessionStore != nil {
+		t.Fatal("expected sessionStore to be unset until first use, matching a Server built without WithSessionStore")
+	}
+
+	store := srv.sessionStoreOrDefault()
+	if store == nil {
+		t.Fatal("expected sessionStoreOrDefault to initialize an in-memory store")
+	}
+
+	if err := store.Put(context.Background(), SessionKey(1), SessionMeta{SecretKey: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if srv.sessionStoreOrDefault() != store {
+		t.Fatal("expected sessionStoreOrDefault to keep returning the same store once initialized")
+	}
+}
+
+func TestEndConnDeletesFromSessionStore(t *testing.T) {
+	store := newMemorySessionStore()
+	srv := &Server{sessions: NewSessions(), sessionStore: store}
+
+	ctx := NewConnContext(context.Background())
+	processID, _ := srv.defaultBackendKeyData(ctx)
+
+	if _, err := store.Lookup(ctx, SessionKey(processID)); err != nil {
+		t.Fatalf("expected session to be published to the store: %v", err)
+	}
+
+	srv.EndConn(ctx)
+
+	if _, err := store.Lookup(ctx, SessionKey(processID)); err != ErrSessionNotFound {
+		t.Fatalf("expected EndConn to delete the session from the store, got err=%v", err)
+	}
+}
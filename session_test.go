@@ -0,0 +1,133 @@
+package wire
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSessionsRegistry(t *testing.T) {
+	sessions := NewSessions()
+
+	session := &Session{ProcessID: 1, SecretKey: 42}
+	sessions.register(session)
+
+	if _, ok := sessions.Get(1); !ok {
+		t.Fatal("expected registered session to be found")
+	}
+
+	if len(sessions.List()) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(sessions.List()))
+	}
+
+	cancelled := false
+	session.Cancel = func() { cancelled = true }
+
+	if err := sessions.Kill(1); err != nil {
+		t.Fatalf("unexpected error killing session: %v", err)
+	}
+
+	if !cancelled {
+		t.Fatal("expected Kill to invoke the session's cancel function")
+	}
+
+	if err := sessions.Kill(2); err == nil {
+		t.Fatal("expected an error killing an unknown session")
+	}
+
+	sessions.unregister(1)
+	if _, ok := sessions.Get(1); ok {
+		t.Fatal("expected session to be removed from the registry")
+	}
+}
+
+func TestNewConnContextPopulatedByBackendKeyData(t *testing.T) {
+	srv := &Server{sessions: NewSessions()}
+
+	ctx := NewConnContext(context.Background())
+	if CurrentSession(ctx) == nil {
+		t.Fatal("expected NewConnContext to attach a Session reachable via CurrentSession")
+	}
+
+	processID, secretKey := srv.defaultBackendKeyData(ctx)
+
+	session := CurrentSession(ctx)
+	if session == nil {
+		t.Fatal("expected a Session to still be attached after defaultBackendKeyData")
+	}
+
+	if session.ProcessID != processID || session.SecretKey != secretKey {
+		t.Fatalf("expected the context's Session to be populated with %d/%d, got %d/%d",
+			processID, secretKey, session.ProcessID, session.SecretKey)
+	}
+
+	if _, ok := srv.sessions.Get(processID); !ok {
+		t.Fatal("expected defaultBackendKeyData to register the session")
+	}
+}
+
+func TestLookupConnSessionResolvesByRemoteAddress(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5432}
+	session := &Session{ProcessID: 7}
+
+	registerConnSession(addr, session)
+	defer unregisterConnSession(addr)
+
+	got, ok := lookupConnSession(addr)
+	if !ok || got != session {
+		t.Fatalf("expected lookupConnSession to resolve the registered session, got %+v ok=%v", got, ok)
+	}
+
+	if _, ok := lookupConnSession(&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5433}); ok {
+		t.Fatal("expected no session registered for an unrelated address")
+	}
+}
+
+func TestDefaultBackendKeyDataAutoEndsSessionWhenContextDone(t *testing.T) {
+	srv := &Server{sessions: NewSessions()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	processID, _ := srv.defaultBackendKeyData(ctx)
+
+	if _, ok := srv.sessions.Get(processID); !ok {
+		t.Fatal("expected session to be registered")
+	}
+
+	cancel()
+
+	if !waitUntil(func() bool {
+		_, ok := srv.sessions.Get(processID)
+		return !ok
+	}) {
+		t.Fatal("expected the session to be unregistered once its context was done")
+	}
+}
+
+func waitUntil(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestEndConnRemovesSession(t *testing.T) {
+	srv := &Server{sessions: NewSessions()}
+
+	ctx := NewConnContext(context.Background())
+	processID, _ := srv.defaultBackendKeyData(ctx)
+
+	if _, ok := srv.sessions.Get(processID); !ok {
+		t.Fatal("expected session to be registered")
+	}
+
+	srv.EndConn(ctx)
+
+	if _, ok := srv.sessions.Get(processID); ok {
+		t.Fatal("expected EndConn to remove the session from the registry")
+	}
+}